@@ -0,0 +1,108 @@
+// Copyright 2020 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+const kubectlCommand = "kubectl"
+
+// ensureApply loads the application's manifests and applies them to every
+// target cluster, ordering each cluster's manifests for install. Manifests
+// with no target cluster (the common, single-cluster case) are applied
+// using kubectl's current context.
+func (e *Executor) ensureApply(ctx context.Context) error {
+	manifests, err := e.loadManifests(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load manifests: %w", err)
+	}
+
+	for cluster, group := range GroupManifestsByCluster(manifests) {
+		kubeContext := e.config.Input.ClusterKubeContexts[cluster]
+		for _, m := range SortManifests(group, InstallOrder) {
+			if err := applyManifest(ctx, m, kubeContext); err != nil {
+				return fmt.Errorf("failed to apply %s to cluster %q: %w", m.ResourceKey(), cluster, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ensureDelete loads the application's manifests and deletes them from every
+// target cluster, ordering each cluster's manifests for uninstall. Manifests
+// with no target cluster (the common, single-cluster case) are deleted
+// using kubectl's current context.
+func (e *Executor) ensureDelete(ctx context.Context) error {
+	manifests, err := e.loadManifests(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load manifests: %w", err)
+	}
+
+	for cluster, group := range GroupManifestsByCluster(manifests) {
+		kubeContext := e.config.Input.ClusterKubeContexts[cluster]
+		for _, m := range SortManifests(group, UninstallOrder) {
+			if err := deleteManifest(ctx, m, kubeContext); err != nil {
+				return fmt.Errorf("failed to delete %s from cluster %q: %w", m.ResourceKey(), cluster, err)
+			}
+		}
+	}
+	return nil
+}
+
+// applyManifest runs `kubectl apply` for a single manifest, optionally
+// against the given kube-context. An empty kubeContext uses kubectl's
+// current context.
+func applyManifest(ctx context.Context, m Manifest, kubeContext string) error {
+	data, err := m.YamlBytes()
+	if err != nil {
+		return err
+	}
+	return runKubectlCommand(ctx, kubeContext, data, "apply", "-f", "-")
+}
+
+// deleteManifest runs `kubectl delete` for a single manifest, optionally
+// against the given kube-context. An empty kubeContext uses kubectl's
+// current context.
+func deleteManifest(ctx context.Context, m Manifest, kubeContext string) error {
+	data, err := m.YamlBytes()
+	if err != nil {
+		return err
+	}
+	return runKubectlCommand(ctx, kubeContext, data, "delete", "-f", "-")
+}
+
+// runKubectlCommand is the pluggable entry point for invoking kubectl,
+// feeding stdin with the manifest YAML to apply/delete.
+var runKubectlCommand = runKubectlCommandExec
+
+func runKubectlCommandExec(ctx context.Context, kubeContext string, stdin []byte, args ...string) error {
+	if kubeContext != "" {
+		args = append([]string{"--context", kubeContext}, args...)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, kubectlCommand, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s", err, stderr.String())
+	}
+	return nil
+}
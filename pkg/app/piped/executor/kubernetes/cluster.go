@@ -0,0 +1,157 @@
+// Copyright 2020 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PredefinedAnnotationTargetCluster is stamped onto a Manifest to record
+// which cluster it should be applied to when a single application's
+// manifests are fanned out to multiple clusters.
+const PredefinedAnnotationTargetCluster = "pipecd.dev/target-cluster"
+
+// SetTargetCluster stamps the manifest with the PredefinedAnnotationTargetCluster
+// annotation, marking it for the named cluster.
+func (m Manifest) SetTargetCluster(name string) {
+	m.AddAnnotations(map[string]string{
+		PredefinedAnnotationTargetCluster: name,
+	})
+}
+
+// TargetCluster returns the cluster this manifest was stamped for, or "" if
+// it was never assigned one.
+func (m Manifest) TargetCluster() string {
+	return m.u.GetAnnotations()[PredefinedAnnotationTargetCluster]
+}
+
+// FilterManifestsByCluster returns the subset of ms stamped for the given
+// cluster.
+func FilterManifestsByCluster(ms []Manifest, cluster string) []Manifest {
+	filtered := make([]Manifest, 0, len(ms))
+	for _, m := range ms {
+		if m.TargetCluster() == cluster {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+// applyClusterOverrides applies overrides, a map of JSON pointer (RFC 6901)
+// to replacement value, onto obj, creating any intermediate maps the
+// pointer paths require.
+func applyClusterOverrides(obj map[string]interface{}, overrides map[string]interface{}) error {
+	for pointer, value := range overrides {
+		if err := setByJSONPointer(obj, pointer, value); err != nil {
+			return fmt.Errorf("failed to apply override %s: %w", pointer, err)
+		}
+	}
+	return nil
+}
+
+// setByJSONPointer sets value at the given RFC 6901 JSON pointer inside obj,
+// creating intermediate maps as needed. Array indices in the pointer must
+// refer to an existing element.
+func setByJSONPointer(obj map[string]interface{}, pointer string, value interface{}) error {
+	if pointer == "" {
+		return fmt.Errorf("empty JSON pointer")
+	}
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, t := range tokens {
+		tokens[i] = unescapeJSONPointerToken(t)
+	}
+
+	return setByTokens(obj, tokens, value)
+}
+
+func setByTokens(node interface{}, tokens []string, value interface{}) error {
+	token := tokens[0]
+	last := len(tokens) == 1
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if last {
+			n[token] = value
+			return nil
+		}
+		child, ok := n[token]
+		if !ok {
+			child = map[string]interface{}{}
+			n[token] = child
+		}
+		return setByTokens(child, tokens[1:], value)
+	case []interface{}:
+		idx, err := strconv.Atoi(token)
+		if err != nil || idx < 0 || idx >= len(n) {
+			return fmt.Errorf("invalid array index %q", token)
+		}
+		if last {
+			n[idx] = value
+			return nil
+		}
+		return setByTokens(n[idx], tokens[1:], value)
+	default:
+		return fmt.Errorf("cannot traverse into a non-container value at %q", token)
+	}
+}
+
+func unescapeJSONPointerToken(token string) string {
+	token = strings.ReplaceAll(token, "~1", "/")
+	token = strings.ReplaceAll(token, "~0", "~")
+	return token
+}
+
+// GroupManifestsByCluster groups manifests by the cluster they were stamped
+// for. An Executor's per-cluster apply loop uses this to select the
+// kubeconfig/context for each group before applying it.
+func GroupManifestsByCluster(ms []Manifest) map[string][]Manifest {
+	groups := make(map[string][]Manifest)
+	for _, m := range ms {
+		cluster := m.TargetCluster()
+		groups[cluster] = append(groups[cluster], m)
+	}
+	return groups
+}
+
+// duplicateManifestsForClusters duplicates every manifest that has no
+// explicit target cluster once per declared cluster, stamping each copy with
+// SetTargetCluster and applying that cluster's overlay values. Manifests that
+// already carry a target-cluster annotation are passed through untouched.
+func duplicateManifestsForClusters(ms []Manifest, clusters []string, overrides map[string]map[string]interface{}) ([]Manifest, error) {
+	if len(clusters) == 0 {
+		return ms, nil
+	}
+
+	result := make([]Manifest, 0, len(ms)*len(clusters))
+	for _, m := range ms {
+		if m.TargetCluster() != "" {
+			result = append(result, m)
+			continue
+		}
+
+		for _, cluster := range clusters {
+			dup := m.Duplicate(m.Name)
+			dup.SetTargetCluster(cluster)
+			if err := applyClusterOverrides(dup.u.Object, overrides[cluster]); err != nil {
+				return nil, fmt.Errorf("failed to apply overrides for cluster %s to %s: %w", cluster, dup.ResourceKey(), err)
+			}
+			dup.refreshCachedFields()
+			result = append(result, dup)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSetTargetClusterAndFilterManifestsByCluster(t *testing.T) {
+	m := mustParseManifest(t, "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\n  namespace: default\n")
+	m.SetTargetCluster("cluster-a")
+
+	assert.Equal(t, "cluster-a", m.TargetCluster())
+
+	filtered := FilterManifestsByCluster([]Manifest{m}, "cluster-a")
+	require.Len(t, filtered, 1)
+
+	filtered = FilterManifestsByCluster([]Manifest{m}, "cluster-b")
+	assert.Empty(t, filtered)
+}
+
+func TestDuplicateManifestsForClusters(t *testing.T) {
+	m := mustParseManifest(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: default
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+        - name: app
+          image: gcr.io/app/app:v1
+`)
+
+	overrides := map[string]map[string]interface{}{
+		"cluster-a": {
+			"/metadata/namespace": "cluster-a-ns",
+		},
+		"cluster-b": {
+			"/metadata/namespace": "cluster-b-ns",
+			"/spec/template/spec/containers/0/image": "gcr.io/app/app:v1-b",
+		},
+	}
+
+	result, err := duplicateManifestsForClusters([]Manifest{m}, []string{"cluster-a", "cluster-b"}, overrides)
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+
+	byCluster := GroupManifestsByCluster(result)
+	require.Len(t, byCluster["cluster-a"], 1)
+	require.Len(t, byCluster["cluster-b"], 1)
+
+	a := byCluster["cluster-a"][0]
+	assert.Equal(t, "cluster-a-ns", a.Namespace)
+
+	b := byCluster["cluster-b"][0]
+	assert.Equal(t, "cluster-b-ns", b.Namespace)
+
+	containers, found, err := unstructured.NestedSlice(b.u.Object, "spec", "template", "spec", "containers")
+	require.NoError(t, err)
+	require.True(t, found)
+	image, _, _ := unstructured.NestedString(containers[0].(map[string]interface{}), "image")
+	assert.Equal(t, "gcr.io/app/app:v1-b", image)
+}
@@ -0,0 +1,310 @@
+// Copyright 2020 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ignoredDiffFields lists the fields the apiserver injects on write, which
+// must not be treated as drift between a desired and a live manifest.
+var ignoredDiffFields = [][]string{
+	{"metadata", "resourceVersion"},
+	{"metadata", "uid"},
+	{"metadata", "generation"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "managedFields"},
+	{"status"},
+}
+
+// DiffNode is a single field-level difference between a desired and a live
+// manifest, identified by its path inside the object tree.
+type DiffNode struct {
+	Path   []string
+	Before interface{} // value on the desired side, nil if the field was added
+	After  interface{} // value on the live side, nil if the field was removed
+}
+
+func (n DiffNode) String() string {
+	return fmt.Sprintf("%s: %v -> %v", strings.Join(n.Path, "."), n.Before, n.After)
+}
+
+// DiffResult is the structural diff between one desired Manifest and its live
+// counterpart.
+type DiffResult struct {
+	Nodes []DiffNode
+}
+
+// HasDiff reports whether any field differs between the two manifests.
+func (r *DiffResult) HasDiff() bool {
+	return len(r.Nodes) > 0
+}
+
+func (r *DiffResult) String() string {
+	lines := make([]string, 0, len(r.Nodes))
+	for _, n := range r.Nodes {
+		lines = append(lines, "  "+n.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Diff computes a structural diff between m (the desired manifest) and live
+// (its counterpart already applied to the cluster). Server-injected fields
+// are ignored, numeric types are normalized, null is treated as unset, and
+// lists of objects that carry a "name" key are compared as keyed maps rather
+// than positional slices, mirroring Kubernetes' strategic-merge semantics.
+func (m Manifest) Diff(live Manifest) (*DiffResult, error) {
+	desired := cleanForDiff(m.u.DeepCopy().Object)
+	liveObj := cleanForDiff(live.u.DeepCopy().Object)
+
+	nodes := diffValues(nil, desired, liveObj)
+	return &DiffResult{Nodes: nodes}, nil
+}
+
+// cleanForDiff strips the server-injected fields from a copy of obj.
+func cleanForDiff(obj map[string]interface{}) map[string]interface{} {
+	for _, path := range ignoredDiffFields {
+		unstructured.RemoveNestedField(obj, path...)
+	}
+	return obj
+}
+
+// diffValues recursively compares before and after, returning one DiffNode
+// per leaf field that differs.
+func diffValues(path []string, before, after interface{}) []DiffNode {
+	before = normalizeDiffValue(before)
+	after = normalizeDiffValue(after)
+
+	if before == nil && after == nil {
+		return nil
+	}
+
+	beforeMap, beforeIsMap := before.(map[string]interface{})
+	afterMap, afterIsMap := after.(map[string]interface{})
+	if beforeIsMap || afterIsMap {
+		return diffMaps(path, beforeMap, afterMap)
+	}
+
+	beforeList, beforeIsList := before.([]interface{})
+	afterList, afterIsList := after.([]interface{})
+	if beforeIsList || afterIsList {
+		return diffLists(path, beforeList, afterList)
+	}
+
+	if before == after {
+		return nil
+	}
+	return []DiffNode{{Path: path, Before: before, After: after}}
+}
+
+func diffMaps(path []string, before, after map[string]interface{}) []DiffNode {
+	keys := make(map[string]struct{}, len(before)+len(after))
+	for k := range before {
+		keys[k] = struct{}{}
+	}
+	for k := range after {
+		keys[k] = struct{}{}
+	}
+
+	sortedKeys := make([]string, 0, len(keys))
+	for k := range keys {
+		sortedKeys = append(sortedKeys, k)
+	}
+	sort.Strings(sortedKeys)
+
+	var nodes []DiffNode
+	for _, k := range sortedKeys {
+		nodes = append(nodes, diffValues(append(append([]string{}, path...), k), before[k], after[k])...)
+	}
+	return nodes
+}
+
+// diffLists compares two lists. If both lists are non-empty and every element
+// of both is a map with a "name" key, they are compared as keyed maps
+// (matching how Kubernetes' strategic-merge patch treats named list items);
+// otherwise, including when either list is empty, they are compared
+// positionally.
+func diffLists(path []string, before, after []interface{}) []DiffNode {
+	if isNamedObjectList(before) && isNamedObjectList(after) {
+		return diffNamedLists(path, before, after)
+	}
+
+	var nodes []DiffNode
+	max := len(before)
+	if len(after) > max {
+		max = len(after)
+	}
+	for i := 0; i < max; i++ {
+		var b, a interface{}
+		if i < len(before) {
+			b = before[i]
+		}
+		if i < len(after) {
+			a = after[i]
+		}
+		nodes = append(nodes, diffValues(append(append([]string{}, path...), fmt.Sprintf("[%d]", i)), b, a)...)
+	}
+	return nodes
+}
+
+func isNamedObjectList(list []interface{}) bool {
+	if len(list) == 0 {
+		return false
+	}
+	for _, item := range list {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if _, ok := obj["name"].(string); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func diffNamedLists(path []string, before, after []interface{}) []DiffNode {
+	index := func(list []interface{}) map[string]interface{} {
+		m := make(map[string]interface{}, len(list))
+		for _, item := range list {
+			obj := item.(map[string]interface{})
+			m[obj["name"].(string)] = obj
+		}
+		return m
+	}
+	beforeByName := index(before)
+	afterByName := index(after)
+
+	names := make(map[string]struct{}, len(beforeByName)+len(afterByName))
+	for n := range beforeByName {
+		names[n] = struct{}{}
+	}
+	for n := range afterByName {
+		names[n] = struct{}{}
+	}
+	sortedNames := make([]string, 0, len(names))
+	for n := range names {
+		sortedNames = append(sortedNames, n)
+	}
+	sort.Strings(sortedNames)
+
+	var nodes []DiffNode
+	for _, n := range sortedNames {
+		nodes = append(nodes, diffValues(append(append([]string{}, path...), fmt.Sprintf("[name=%s]", n)), beforeByName[n], afterByName[n])...)
+	}
+	return nodes
+}
+
+// normalizeDiffValue maps equivalent encodings of the same value onto a
+// single representation: int/int32/int64 collapse to float64 (matching how
+// numbers parsed from YAML/JSON are represented), and null collapses to Go
+// nil (unset).
+func normalizeDiffValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case int:
+		return float64(t)
+	case int32:
+		return float64(t)
+	case int64:
+		return float64(t)
+	default:
+		return v
+	}
+}
+
+// DiffReport summarizes the drift between a desired and a live set of
+// manifests.
+type DiffReport struct {
+	Added   []ResourceKey
+	Removed []ResourceKey
+	Changed []ResourceKey
+
+	results map[string]*DiffResult
+}
+
+// Result returns the structural diff computed for the given changed
+// resource, if any.
+func (r DiffReport) Result(key ResourceKey) (*DiffResult, bool) {
+	res, ok := r.results[key.String()]
+	return res, ok
+}
+
+// String renders the report as a unified-style listing keyed by each
+// resource's ResourceKey.
+func (r DiffReport) String() string {
+	var b strings.Builder
+	for _, k := range r.Added {
+		fmt.Fprintf(&b, "+ %s\n", k)
+	}
+	for _, k := range r.Removed {
+		fmt.Fprintf(&b, "- %s\n", k)
+	}
+	for _, k := range r.Changed {
+		fmt.Fprintf(&b, "~ %s\n%s\n", k, r.results[k.String()])
+	}
+	return b.String()
+}
+
+// DiffManifests computes the drift between a desired and a live set of
+// manifests, matching them up by ResourceKey.
+func DiffManifests(desired, live []Manifest) DiffReport {
+	liveByKey := make(map[string]Manifest, len(live))
+	for _, m := range live {
+		liveByKey[m.ResourceKey()] = m
+	}
+
+	report := DiffReport{results: make(map[string]*DiffResult)}
+
+	for _, d := range desired {
+		key := d.ResourceKey()
+		l, ok := liveByKey[key]
+		if !ok {
+			report.Added = append(report.Added, d.toResourceKey())
+			continue
+		}
+		delete(liveByKey, key)
+
+		result, err := d.Diff(l)
+		if err != nil || !result.HasDiff() {
+			continue
+		}
+		report.Changed = append(report.Changed, d.toResourceKey())
+		report.results[key] = result
+	}
+
+	remainingKeys := make([]string, 0, len(liveByKey))
+	for key := range liveByKey {
+		remainingKeys = append(remainingKeys, key)
+	}
+	sort.Strings(remainingKeys)
+	for _, key := range remainingKeys {
+		report.Removed = append(report.Removed, liveByKey[key].toResourceKey())
+	}
+
+	return report
+}
+
+func (m Manifest) toResourceKey() ResourceKey {
+	return ResourceKey{
+		APIVersion: m.APIVersion,
+		Kind:       m.Kind,
+		Namespace:  m.Namespace,
+		Name:       m.Name,
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustParseManifest(t *testing.T, data string) Manifest {
+	t.Helper()
+	m, err := parseManifest(data)
+	require.NoError(t, err)
+	return m
+}
+
+func TestManifestDiff_IgnoresServerInjectedFields(t *testing.T) {
+	desired := mustParseManifest(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: testapp
+  namespace: default
+spec:
+  replicas: 3
+`)
+	live := mustParseManifest(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: testapp
+  namespace: default
+  resourceVersion: "123"
+  uid: abc-def
+  generation: 2
+  creationTimestamp: "2020-01-01T00:00:00Z"
+spec:
+  replicas: 3
+status:
+  availableReplicas: 3
+`)
+
+	result, err := desired.Diff(live)
+	require.NoError(t, err)
+	assert.False(t, result.HasDiff())
+}
+
+func TestManifestDiff_DetectsChangedField(t *testing.T) {
+	desired := mustParseManifest(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: testapp
+  namespace: default
+spec:
+  replicas: 3
+`)
+	live := mustParseManifest(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: testapp
+  namespace: default
+spec:
+  replicas: 1
+`)
+
+	result, err := desired.Diff(live)
+	require.NoError(t, err)
+	require.True(t, result.HasDiff())
+	require.Len(t, result.Nodes, 1)
+	assert.Equal(t, []string{"spec", "replicas"}, result.Nodes[0].Path)
+}
+
+func TestManifestDiff_ComparesNamedListsByKeyNotPosition(t *testing.T) {
+	desired := mustParseManifest(t, `
+apiVersion: v1
+kind: Service
+metadata:
+  name: testsvc
+  namespace: default
+spec:
+  ports:
+    - name: http
+      port: 80
+    - name: grpc
+      port: 9090
+`)
+	live := mustParseManifest(t, `
+apiVersion: v1
+kind: Service
+metadata:
+  name: testsvc
+  namespace: default
+spec:
+  ports:
+    - name: grpc
+      port: 9090
+    - name: http
+      port: 80
+`)
+
+	result, err := desired.Diff(live)
+	require.NoError(t, err)
+	assert.False(t, result.HasDiff())
+}
+
+func TestDiffManifests(t *testing.T) {
+	desired := []Manifest{
+		mustParseManifest(t, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  namespace: default\n"),
+		mustParseManifest(t, "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: b\n  namespace: default\nspec:\n  replicas: 3\n"),
+	}
+	live := []Manifest{
+		mustParseManifest(t, "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: b\n  namespace: default\nspec:\n  replicas: 1\n"),
+		mustParseManifest(t, "apiVersion: v1\nkind: Service\nmetadata:\n  name: c\n  namespace: default\n"),
+	}
+
+	report := DiffManifests(desired, live)
+
+	require.Len(t, report.Added, 1)
+	assert.Equal(t, "a", report.Added[0].Name)
+
+	require.Len(t, report.Removed, 1)
+	assert.Equal(t, "c", report.Removed[0].Name)
+
+	require.Len(t, report.Changed, 1)
+	assert.Equal(t, "b", report.Changed[0].Name)
+
+	result, ok := report.Result(report.Changed[0])
+	require.True(t, ok)
+	assert.True(t, result.HasDiff())
+}
@@ -0,0 +1,229 @@
+// Copyright 2020 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/kapetaniosci/pipe/pkg/config"
+)
+
+const helmCommand = "helm"
+
+// templateHelmChart renders the chart referenced by the input configuration
+// and returns the resulting list of Manifest. Any SOPS-encrypted source file
+// under appDirPath (a local chart, a values file, ...) is decrypted through
+// decrypter before it is ever handed to the helm binary.
+func templateHelmChart(ctx context.Context, appDirPath string, input config.KubernetesDeploymentInput, decrypter SecretDecrypter) ([]Manifest, error) {
+	decryptedAppDirPath, err := decryptDirToTemp(ctx, appDirPath, decrypter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt application source at %s: %w", appDirPath, err)
+	}
+	appDirPath = decryptedAppDirPath
+
+	chartPath, err := prepareHelmChart(ctx, appDirPath, input.HelmChart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare helm chart: %w", err)
+	}
+
+	if err := fetchHelmChartDependencies(ctx, chartPath); err != nil {
+		return nil, fmt.Errorf("failed to fetch helm chart dependencies: %w", err)
+	}
+
+	args := buildHelmTemplateArgs(appDirPath, input, chartPath)
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, helmCommand, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to render helm chart %s: %w: %s", chartPath, err, stderr.String())
+	}
+
+	return parseHelmTemplateOutput(stdout.Bytes())
+}
+
+// prepareHelmChart resolves the chart source and returns a local path ready to
+// be templated. A chart with no Repository is treated as a directory relative
+// to appDirPath, otherwise it is pulled from the configured repository.
+func prepareHelmChart(ctx context.Context, appDirPath string, chart config.HelmChart) (string, error) {
+	if chart.Repository == "" {
+		if chart.Name == "" {
+			return appDirPath, nil
+		}
+		return filepath.Join(appDirPath, chart.Name), nil
+	}
+
+	pullDir, err := os.MkdirTemp("", "pipecd-helm-chart")
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{
+		"pull",
+		"--untar",
+		"--untardir", pullDir,
+		"--repo", chart.Repository,
+		chart.Name,
+	}
+	if chart.Version != "" {
+		args = append(args, "--version", chart.Version)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, helmCommand, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to pull chart %s from %s: %w: %s", chart.Name, chart.Repository, err, stderr.String())
+	}
+
+	return filepath.Join(pullDir, chart.Name), nil
+}
+
+// fetchHelmChartDependencies fetches the dependencies declared in the chart's
+// Chart.yaml/requirements.yaml before it gets rendered. Charts with no
+// declared dependencies are left untouched.
+func fetchHelmChartDependencies(ctx context.Context, chartPath string) error {
+	hasDeps, err := helmChartHasDependencies(chartPath)
+	if err != nil {
+		return err
+	}
+	if !hasDeps {
+		return nil
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, helmCommand, "dependency", "update", chartPath)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// helmChartHasDependencies reports whether chartPath declares any chart
+// dependency: either a Chart.lock from a previous `helm dependency update`,
+// or a non-empty `dependencies:` list in Chart.yaml (Helm v3) or
+// requirements.yaml (Helm v2).
+func helmChartHasDependencies(chartPath string) (bool, error) {
+	if _, err := os.Stat(filepath.Join(chartPath, "Chart.lock")); err == nil {
+		return true, nil
+	}
+
+	for _, name := range []string{"Chart.yaml", "requirements.yaml"} {
+		has, err := yamlFileHasDependencies(filepath.Join(chartPath, name))
+		if err != nil {
+			return false, err
+		}
+		if has {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// yamlFileHasDependencies reports whether the YAML file at path has a
+// non-empty top-level `dependencies` list. A missing file is not an error.
+func yamlFileHasDependencies(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	var parsed struct {
+		Dependencies []interface{} `json:"dependencies"`
+	}
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return false, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return len(parsed.Dependencies) > 0, nil
+}
+
+func buildHelmTemplateArgs(appDirPath string, input config.KubernetesDeploymentInput, chartPath string) []string {
+	args := []string{"template"}
+
+	releaseName := input.HelmReleaseName
+	if releaseName == "" {
+		releaseName = "release-name"
+	}
+	args = append(args, releaseName, chartPath)
+
+	for _, f := range input.HelmValueFiles {
+		args = append(args, "--values", filepath.Join(appDirPath, f))
+	}
+	if input.Namespace != "" {
+		args = append(args, "--namespace", input.Namespace)
+	}
+
+	return args
+}
+
+// parseHelmTemplateOutput splits the multi-document YAML produced by
+// `helm template` and converts each document into a Manifest, the same way
+// loadManifestsFromYAMLFile does. NOTES and partials (files starting with an
+// underscore) rendered as source comments by helm are skipped.
+func parseHelmTemplateOutput(out []byte) ([]Manifest, error) {
+	var (
+		parts     = strings.Split(string(out), manifestSeperator)
+		manifests = make([]Manifest, 0, len(parts))
+	)
+
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+		if isHelmNonResourceSource(part) {
+			continue
+		}
+		m, err := parseManifest(part)
+		if err != nil {
+			return nil, err
+		}
+		if m.Kind == "" || m.APIVersion == "" {
+			continue
+		}
+		manifests = append(manifests, m)
+	}
+
+	return manifests, nil
+}
+
+// isHelmNonResourceSource reports whether the given rendered document comes
+// from a NOTES.txt or a partial template (name starting with "_"), both of
+// which helm template prefixes with a "# Source:" comment.
+func isHelmNonResourceSource(doc string) bool {
+	for _, line := range strings.Split(doc, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "# Source:") {
+			continue
+		}
+		source := strings.TrimSpace(strings.TrimPrefix(line, "# Source:"))
+		base := filepath.Base(source)
+		return base == "NOTES.txt" || strings.HasPrefix(base, "_")
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kapetaniosci/pipe/pkg/config"
+)
+
+func TestTemplateHelmChart(t *testing.T) {
+	if _, err := exec.LookPath(helmCommand); err != nil {
+		t.Skip("helm command is not installed")
+	}
+
+	input := config.KubernetesDeploymentInput{
+		HelmReleaseName: "testchart",
+	}
+	manifests, err := templateHelmChart(context.Background(), "testdata/testchart", input, NewSOPSDecrypter(config.SecretManagement{}))
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+
+	m := manifests[0]
+	assert.Equal(t, "apps/v1", m.APIVersion)
+	assert.Equal(t, "Deployment", m.Kind)
+	assert.Equal(t, "testchart", m.Name)
+}
+
+func TestIsHelmNonResourceSource(t *testing.T) {
+	testcases := []struct {
+		name     string
+		doc      string
+		expected bool
+	}{
+		{
+			name:     "resource",
+			doc:      "# Source: testchart/templates/deployment.yaml\napiVersion: apps/v1\nkind: Deployment\n",
+			expected: false,
+		},
+		{
+			name:     "notes",
+			doc:      "# Source: testchart/templates/NOTES.txt\nThank you for installing.\n",
+			expected: true,
+		},
+		{
+			name:     "partial",
+			doc:      "# Source: testchart/templates/_helpers.tpl\n",
+			expected: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, isHelmNonResourceSource(tc.doc))
+		})
+	}
+}
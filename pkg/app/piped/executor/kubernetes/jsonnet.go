@@ -0,0 +1,189 @@
+// Copyright 2020 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+
+	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+	"sigs.k8s.io/yaml"
+
+	"github.com/kapetaniosci/pipe/pkg/config"
+)
+
+// templateJsonnetManifests evaluates the jsonnet entrypoint referenced by the
+// input configuration and flattens its output into a list of Manifest.
+func templateJsonnetManifests(appDirPath string, input config.KubernetesDeploymentInput) ([]Manifest, error) {
+	if input.JsonnetEntrypoint == "" {
+		return nil, fmt.Errorf("jsonnet entrypoint is not specified")
+	}
+	entrypoint := filepath.Join(appDirPath, input.JsonnetEntrypoint)
+
+	vm := jsonnet.MakeVM()
+	if len(input.JsonnetLibPaths) > 0 {
+		jpaths := make([]string, 0, len(input.JsonnetLibPaths))
+		for _, p := range input.JsonnetLibPaths {
+			jpaths = append(jpaths, filepath.Join(appDirPath, p))
+		}
+		vm.Importer(&jsonnet.FileImporter{JPaths: jpaths})
+	}
+	registerJsonnetNativeFuncs(vm)
+
+	for name, value := range input.JsonnetExtVars {
+		vm.ExtVar(name, value)
+	}
+	for name, value := range input.JsonnetTLAs {
+		vm.TLAVar(name, value)
+	}
+
+	out, err := vm.EvaluateFile(entrypoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate jsonnet file %s: %w", entrypoint, err)
+	}
+
+	var tree interface{}
+	if err := json.Unmarshal([]byte(out), &tree); err != nil {
+		return nil, fmt.Errorf("failed to parse jsonnet output as JSON: %w", err)
+	}
+
+	resources := make([]map[string]interface{}, 0)
+	flattenJsonnetOutput(tree, &resources)
+
+	manifests := make([]Manifest, 0, len(resources))
+	for _, r := range resources {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		m, err := parseManifest(string(data))
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+	return manifests, nil
+}
+
+// flattenJsonnetOutput walks an arbitrarily nested jsonnet output, appending
+// any object that contains both "apiVersion" and "kind" to resources.
+func flattenJsonnetOutput(node interface{}, resources *[]map[string]interface{}) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if _, hasAPIVersion := v["apiVersion"]; hasAPIVersion {
+			if _, hasKind := v["kind"]; hasKind {
+				*resources = append(*resources, v)
+				return
+			}
+		}
+		for _, child := range v {
+			flattenJsonnetOutput(child, resources)
+		}
+	case []interface{}:
+		for _, child := range v {
+			flattenJsonnetOutput(child, resources)
+		}
+	}
+}
+
+// registerJsonnetNativeFuncs registers the set of native functions commonly
+// expected by Kubernetes-targeted jsonnet code.
+func registerJsonnetNativeFuncs(vm *jsonnet.VM) {
+	vm.NativeFunction(&jsonnet.NativeFunction{
+		Name:   "parseJson",
+		Params: ast.Identifiers{"json"},
+		Func: func(args []interface{}) (interface{}, error) {
+			var v interface{}
+			if err := json.Unmarshal([]byte(args[0].(string)), &v); err != nil {
+				return nil, err
+			}
+			return v, nil
+		},
+	})
+
+	vm.NativeFunction(&jsonnet.NativeFunction{
+		Name:   "parseYaml",
+		Params: ast.Identifiers{"yaml"},
+		Func: func(args []interface{}) (interface{}, error) {
+			var v interface{}
+			if err := yaml.Unmarshal([]byte(args[0].(string)), &v); err != nil {
+				return nil, err
+			}
+			return v, nil
+		},
+	})
+
+	vm.NativeFunction(&jsonnet.NativeFunction{
+		Name:   "manifestJson",
+		Params: ast.Identifiers{"value"},
+		Func: func(args []interface{}) (interface{}, error) {
+			data, err := json.MarshalIndent(args[0], "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			return string(data), nil
+		},
+	})
+
+	vm.NativeFunction(&jsonnet.NativeFunction{
+		Name:   "manifestYaml",
+		Params: ast.Identifiers{"value"},
+		Func: func(args []interface{}) (interface{}, error) {
+			data, err := yaml.Marshal(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return string(data), nil
+		},
+	})
+
+	vm.NativeFunction(&jsonnet.NativeFunction{
+		Name:   "resolveImage",
+		Params: ast.Identifiers{"image", "digest"},
+		Func: func(args []interface{}) (interface{}, error) {
+			image := args[0].(string)
+			digest := args[1].(string)
+			if repo, _, found := splitImageTag(image); found {
+				return fmt.Sprintf("%s@%s", repo, digest), nil
+			}
+			return fmt.Sprintf("%s@%s", image, digest), nil
+		},
+	})
+
+	vm.NativeFunction(&jsonnet.NativeFunction{
+		Name:   "regexMatch",
+		Params: ast.Identifiers{"regex", "string"},
+		Func: func(args []interface{}) (interface{}, error) {
+			return regexp.MatchString(args[0].(string), args[1].(string))
+		},
+	})
+}
+
+// splitImageTag splits a container image reference of the form repo:tag into
+// its repository and tag parts.
+func splitImageTag(image string) (repo, tag string, found bool) {
+	for i := len(image) - 1; i >= 0; i-- {
+		switch image[i] {
+		case ':':
+			return image[:i], image[i+1:], true
+		case '/':
+			return image, "", false
+		}
+	}
+	return image, "", false
+}
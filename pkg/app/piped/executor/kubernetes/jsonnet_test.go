@@ -0,0 +1,90 @@
+// Copyright 2020 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kapetaniosci/pipe/pkg/config"
+)
+
+func TestTemplateJsonnetManifests(t *testing.T) {
+	input := config.KubernetesDeploymentInput{
+		JsonnetEntrypoint: "app.jsonnet",
+		JsonnetExtVars: map[string]string{
+			"name":     "testapp",
+			"replicas": "3",
+		},
+	}
+
+	manifests, err := templateJsonnetManifests("testdata/jsonnet", input)
+	require.NoError(t, err)
+	require.Len(t, manifests, 2)
+
+	keys := make(map[string]Manifest, len(manifests))
+	for _, m := range manifests {
+		keys[m.ResourceKey()] = m
+	}
+
+	deployment, ok := keys["apps/v1:Deployment::testapp"]
+	require.True(t, ok)
+	assert.Equal(t, "testapp", deployment.Name)
+
+	_, ok = keys["v1:Service::testapp"]
+	assert.True(t, ok)
+}
+
+func TestTemplateJsonnetManifests_MultipleLibPaths(t *testing.T) {
+	input := config.KubernetesDeploymentInput{
+		JsonnetEntrypoint: "main.jsonnet",
+		JsonnetLibPaths:   []string{"libs-a", "libs-b"},
+	}
+
+	manifests, err := templateJsonnetManifests("testdata/jsonnet-libpaths", input)
+	require.NoError(t, err)
+	require.Len(t, manifests, 2)
+
+	keys := make(map[string]struct{}, len(manifests))
+	for _, m := range manifests {
+		keys[m.ResourceKey()] = struct{}{}
+	}
+	_, hasDeployment := keys["apps/v1:Deployment::libapp"]
+	_, hasService := keys["v1:Service::libapp"]
+	assert.True(t, hasDeployment)
+	assert.True(t, hasService)
+}
+
+func TestFlattenJsonnetOutput(t *testing.T) {
+	tree := map[string]interface{}{
+		"a": map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+		},
+		"b": []interface{}{
+			map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Secret",
+			},
+			"not a resource",
+		},
+	}
+
+	var resources []map[string]interface{}
+	flattenJsonnetOutput(tree, &resources)
+	assert.Len(t, resources, 2)
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/kapetaniosci/pipe/pkg/config"
+)
+
+const kustomizeCommand = "kustomize"
+
+// templateKustomizeManifests runs kustomize build against the kustomization
+// directory referenced by the input configuration and returns the resulting
+// list of Manifest. Any SOPS-encrypted source file under appDirPath is
+// decrypted through decrypter before it is ever handed to the kustomize
+// binary.
+func templateKustomizeManifests(ctx context.Context, appDirPath string, input config.KubernetesDeploymentInput, decrypter SecretDecrypter) ([]Manifest, error) {
+	decryptedAppDirPath, err := decryptDirToTemp(ctx, appDirPath, decrypter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt application source at %s: %w", appDirPath, err)
+	}
+	appDirPath = decryptedAppDirPath
+
+	dir := appDirPath
+	if input.KustomizationDir != "" {
+		dir = filepath.Join(appDirPath, input.KustomizationDir)
+	}
+
+	out, err := runKustomizeBuild(ctx, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render kustomization at %s: %w", dir, err)
+	}
+
+	manifests, err := parseManifests(out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifests rendered from kustomization at %s: %w", dir, err)
+	}
+	return manifests, nil
+}
+
+// runKustomizeBuild is the pluggable entry point for running `kustomize
+// build`. It is a thin exec.Command wrapper by default so that an embedded
+// sigs.k8s.io/kustomize/api/krusty based implementation can be swapped in
+// without touching callers.
+var runKustomizeBuild = runKustomizeBuildCommand
+
+func runKustomizeBuildCommand(ctx context.Context, dir string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, kustomizeCommand, "build", dir)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
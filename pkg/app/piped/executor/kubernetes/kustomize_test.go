@@ -0,0 +1,79 @@
+// Copyright 2020 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kapetaniosci/pipe/pkg/config"
+)
+
+func TestTemplateKustomizeManifests(t *testing.T) {
+	if _, err := exec.LookPath(kustomizeCommand); err != nil {
+		t.Skip("kustomize command is not installed")
+	}
+
+	input := config.KubernetesDeploymentInput{
+		KustomizationDir: "overlays/staging",
+	}
+	manifests, err := templateKustomizeManifests(context.Background(), "testdata/kustomize", input, NewSOPSDecrypter(config.SecretManagement{}))
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+
+	m := manifests[0]
+	assert.Equal(t, "staging", m.Namespace)
+	assert.Equal(t, "testapp", m.Name)
+	assert.Equal(t, "Deployment", m.Kind)
+}
+
+func TestTemplateKustomizeManifests_ParsesStubbedBuildOutput(t *testing.T) {
+	rendered := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: testapp
+  namespace: staging
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: testapp-config
+  namespace: staging
+`
+	original := runKustomizeBuild
+	defer func() { runKustomizeBuild = original }()
+	runKustomizeBuild = func(ctx context.Context, dir string) ([]byte, error) {
+		return []byte(rendered), nil
+	}
+
+	manifests, err := templateKustomizeManifests(context.Background(), "testdata/kustomize", config.KubernetesDeploymentInput{
+		KustomizationDir: "overlays/staging",
+	}, NewSOPSDecrypter(config.SecretManagement{}))
+	require.NoError(t, err)
+	require.Len(t, manifests, 2)
+
+	keys := make(map[string]struct{}, len(manifests))
+	for _, m := range manifests {
+		keys[m.ResourceKey()] = struct{}{}
+	}
+	_, hasDeployment := keys["apps/v1:Deployment:staging:testapp"]
+	_, hasConfigMap := keys["v1:ConfigMap:staging:testapp-config"]
+	assert.True(t, hasDeployment)
+	assert.True(t, hasConfigMap)
+}
@@ -17,7 +17,6 @@ package kubernetes
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -48,6 +47,17 @@ func (m Manifest) Duplicate(name string) Manifest {
 	}
 }
 
+// refreshCachedFields re-derives APIVersion, Kind, Namespace and Name from
+// the underlying object. Callers that mutate m.u directly (e.g. applying a
+// JSON-pointer override to metadata.namespace) must call this afterwards, or
+// the cached fields used by ResourceKey and sorting go stale.
+func (m *Manifest) refreshCachedFields() {
+	m.APIVersion = m.u.GetAPIVersion()
+	m.Kind = m.u.GetKind()
+	m.Namespace = m.u.GetNamespace()
+	m.Name = m.u.GetName()
+}
+
 func (m Manifest) YamlBytes() ([]byte, error) {
 	return yaml.Marshal(m.u)
 }
@@ -136,18 +146,21 @@ func DecodeResourceKey(key string) (ResourceKey, error) {
 }
 
 func (e *Executor) loadManifests(ctx context.Context) ([]Manifest, error) {
+	decrypter := NewSOPSDecrypter(e.config.SecretManagement)
 	switch e.templatingMethod {
 	case TemplatingMethodHelm:
-		return nil, nil
+		return templateHelmChart(ctx, e.appDirPath, e.config.Input, decrypter)
 	case TemplatingMethodKustomize:
-		return nil, nil
+		return templateKustomizeManifests(ctx, e.appDirPath, e.config.Input, decrypter)
+	case TemplatingMethodJsonnet:
+		return templateJsonnetManifests(e.appDirPath, e.config.Input)
 	case TemplatingMethodNone:
-		return loadPlainYAMLMannifests(ctx, e.appDirPath, e.config.Input.Manifests)
+		return loadPlainYAMLMannifests(ctx, e.appDirPath, e.config.Input.Manifests, e.config.Input.Clusters, e.config.Input.ClusterOverrides, decrypter)
 	}
 	return nil, nil
 }
 
-func loadPlainYAMLMannifests(ctx context.Context, dir string, names []string) ([]Manifest, error) {
+func loadPlainYAMLMannifests(ctx context.Context, dir string, names, clusters []string, clusterOverrides map[string]map[string]interface{}, decrypter SecretDecrypter) ([]Manifest, error) {
 	// If no name was specified we have to walk the app directory to collect the manifest list.
 	if len(names) == 0 {
 		err := filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
@@ -178,25 +191,34 @@ func loadPlainYAMLMannifests(ctx context.Context, dir string, names []string) ([
 	manifests := make([]Manifest, 0, len(names))
 	for _, name := range names {
 		path := filepath.Join(dir, name)
-		ms, err := loadManifestsFromYAMLFile(path)
+		ms, err := loadManifestsFromYAMLFile(ctx, path, decrypter)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load maninifest at %s (%v)", path, err)
 		}
 		manifests = append(manifests, ms...)
 	}
 
-	return manifests, nil
+	return duplicateManifestsForClusters(manifests, clusters, clusterOverrides)
 }
 
-func loadManifestsFromYAMLFile(path string) ([]Manifest, error) {
-	data, err := ioutil.ReadFile(path)
+func loadManifestsFromYAMLFile(ctx context.Context, path string, decrypter SecretDecrypter) ([]Manifest, error) {
+	data, err := decryptManifestFile(ctx, path, decrypter)
 	if err != nil {
 		return nil, err
 	}
+	return parseManifests(data)
+}
+
+// manifestSeperator is the separator used to split a multi-document YAML
+// stream, shared by the plain YAML, Helm and Kustomize paths so their output
+// all funnels through the same Manifest construction path.
+const manifestSeperator = "\n---"
 
-	const seperator = "\n---"
+// parseManifests splits a multi-document YAML byte stream and converts each
+// document into a Manifest.
+func parseManifests(data []byte) ([]Manifest, error) {
 	var (
-		parts     = strings.Split(string(data), seperator)
+		parts     = strings.Split(string(data), manifestSeperator)
 		manifests = make([]Manifest, 0, len(parts))
 	)
 
@@ -206,17 +228,26 @@ func loadManifestsFromYAMLFile(path string) ([]Manifest, error) {
 		if len(part) == 0 {
 			continue
 		}
-		var obj unstructured.Unstructured
-		if err := yaml.Unmarshal([]byte(part), &obj); err != nil {
+		m, err := parseManifest(part)
+		if err != nil {
 			return nil, err
 		}
-		manifests = append(manifests, Manifest{
-			APIVersion: obj.GetAPIVersion(),
-			Kind:       obj.GetKind(),
-			Namespace:  obj.GetNamespace(),
-			Name:       obj.GetName(),
-			u:          &obj,
-		})
+		manifests = append(manifests, m)
 	}
 	return manifests, nil
 }
+
+// parseManifest unmarshals a single YAML document into a Manifest.
+func parseManifest(data string) (Manifest, error) {
+	var obj unstructured.Unstructured
+	if err := yaml.Unmarshal([]byte(data), &obj); err != nil {
+		return Manifest{}, err
+	}
+	return Manifest{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		u:          &obj,
+	}, nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dryDecrypter is a fake SecretDecrypter used by tests so they don't need
+// real KMS access; it just records the paths it was asked to decrypt.
+type dryDecrypter struct {
+	plaintext []byte
+	seen      []string
+}
+
+func (d *dryDecrypter) Decrypt(ctx context.Context, path string, data []byte) ([]byte, error) {
+	d.seen = append(d.seen, path)
+	return d.plaintext, nil
+}
+
+func TestIsEncryptedManifest(t *testing.T) {
+	testcases := []struct {
+		name     string
+		path     string
+		data     string
+		expected bool
+	}{
+		{
+			name:     "enc.yaml suffix",
+			path:     "secret.enc.yaml",
+			data:     "apiVersion: v1\nkind: Secret\n",
+			expected: true,
+		},
+		{
+			name:     "enc.yml suffix",
+			path:     "secret.enc.yml",
+			data:     "apiVersion: v1\nkind: Secret\n",
+			expected: true,
+		},
+		{
+			name:     "sops metadata block",
+			path:     "secret.yaml",
+			data:     "apiVersion: v1\nkind: Secret\nsops:\n    kms: []\n",
+			expected: true,
+		},
+		{
+			name:     "plain manifest",
+			path:     "deployment.yaml",
+			data:     "apiVersion: apps/v1\nkind: Deployment\n",
+			expected: false,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, isEncryptedManifest(tc.path, []byte(tc.data)))
+		})
+	}
+}
+
+func TestDecryptManifestFile(t *testing.T) {
+	dir := t.TempDir()
+
+	plainPath := filepath.Join(dir, "deployment.yaml")
+	require.NoError(t, ioutil.WriteFile(plainPath, []byte("apiVersion: apps/v1\nkind: Deployment\n"), 0600))
+
+	encPath := filepath.Join(dir, "secret.enc.yaml")
+	require.NoError(t, ioutil.WriteFile(encPath, []byte("sops:\n    kms: []\nENC[data]\n"), 0600))
+
+	decrypter := &dryDecrypter{plaintext: []byte("apiVersion: v1\nkind: Secret\n")}
+
+	data, err := decryptManifestFile(context.Background(), plainPath, decrypter)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Deployment")
+	assert.Empty(t, decrypter.seen)
+
+	data, err = decryptManifestFile(context.Background(), encPath, decrypter)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Secret")
+	assert.Equal(t, []string{encPath}, decrypter.seen)
+}
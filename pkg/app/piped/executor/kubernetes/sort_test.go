@@ -0,0 +1,116 @@
+// Copyright 2020 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func manifestOf(kind, namespace, name string) Manifest {
+	return Manifest{Kind: kind, Namespace: namespace, Name: name}
+}
+
+func kindsOf(ms []Manifest) []string {
+	kinds := make([]string, len(ms))
+	for i, m := range ms {
+		kinds[i] = m.Kind
+	}
+	return kinds
+}
+
+func TestSortManifests(t *testing.T) {
+	testcases := []struct {
+		name     string
+		input    []Manifest
+		order    SortOrder
+		expected []string
+	}{
+		{
+			name: "install order orders namespace before deployment",
+			input: []Manifest{
+				manifestOf("Deployment", "default", "app"),
+				manifestOf("Namespace", "", "default"),
+				manifestOf("ConfigMap", "default", "app-config"),
+			},
+			order:    InstallOrder,
+			expected: []string{"Namespace", "ConfigMap", "Deployment"},
+		},
+		{
+			name: "uninstall order is the reverse of install order",
+			input: []Manifest{
+				manifestOf("Deployment", "default", "app"),
+				manifestOf("Namespace", "", "default"),
+				manifestOf("ConfigMap", "default", "app-config"),
+			},
+			order:    UninstallOrder,
+			expected: []string{"Deployment", "ConfigMap", "Namespace"},
+		},
+		{
+			name: "custom resource definitions precede custom resources",
+			input: []Manifest{
+				manifestOf("MyCustomResource", "default", "instance"),
+				manifestOf("CustomResourceDefinition", "", "mycustomresources.example.com"),
+			},
+			order:    InstallOrder,
+			expected: []string{"CustomResourceDefinition", "MyCustomResource"},
+		},
+		{
+			name: "unknown kinds sort last alphabetically without crashing",
+			input: []Manifest{
+				manifestOf("ZKind", "default", "z"),
+				manifestOf("AKind", "default", "a"),
+				manifestOf("Deployment", "default", "app"),
+			},
+			order:    InstallOrder,
+			expected: []string{"Deployment", "AKind", "ZKind"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := SortManifests(tc.input, tc.order)
+			assert.Equal(t, tc.expected, kindsOf(got))
+		})
+	}
+}
+
+func TestSortManifests_TiebreakByNamespaceThenName(t *testing.T) {
+	input := []Manifest{
+		manifestOf("Deployment", "b-ns", "app"),
+		manifestOf("Deployment", "a-ns", "b-app"),
+		manifestOf("Deployment", "a-ns", "a-app"),
+	}
+
+	got := SortManifests(input, InstallOrder)
+	assert.Equal(t, []Manifest{
+		manifestOf("Deployment", "a-ns", "a-app"),
+		manifestOf("Deployment", "a-ns", "b-app"),
+		manifestOf("Deployment", "b-ns", "app"),
+	}, got)
+}
+
+func TestSortManifests_DoesNotMutateInput(t *testing.T) {
+	input := []Manifest{
+		manifestOf("Deployment", "default", "app"),
+		manifestOf("Namespace", "", "default"),
+	}
+	original := append([]Manifest(nil), input...)
+
+	SortManifests(input, InstallOrder)
+
+	assert.Equal(t, original, input)
+}
@@ -0,0 +1,109 @@
+// Copyright 2020 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+// DeploymentConfigurationFileName is the name of the file, inside an
+// application directory, that holds its deployment configuration. It is
+// never treated as a Kubernetes manifest even when no explicit manifest
+// list is given.
+const DeploymentConfigurationFileName = ".pipecd.yaml"
+
+// KubernetesDeploymentConfig represents the deployment configuration for a
+// Kubernetes application.
+type KubernetesDeploymentConfig struct {
+	Input KubernetesDeploymentInput `json:"input"`
+	// SecretManagement configures which secret backend(s) the piped should
+	// use to decrypt SOPS-encrypted manifests belonging to this
+	// application.
+	SecretManagement SecretManagement `json:"secretManagement"`
+}
+
+// SecretManagement configures the backend(s) used to decrypt SOPS-encrypted
+// secrets. Any combination may be set; each non-empty field is passed
+// through to the corresponding sops CLI flag.
+type SecretManagement struct {
+	// KMSKeyIDs is the list of AWS KMS key ARNs sops may decrypt with.
+	KMSKeyIDs []string `json:"kmsKeyIds"`
+	// GCPKMSResourceIDs is the list of GCP KMS key resource IDs sops may
+	// decrypt with.
+	GCPKMSResourceIDs []string `json:"gcpKmsResourceIds"`
+	// AgeRecipients is the list of age recipients sops may decrypt with.
+	AgeRecipients []string `json:"ageRecipients"`
+}
+
+// KubernetesDeploymentInput contains the configuration needed to load the
+// manifests of a Kubernetes application, whichever templating method is
+// used.
+type KubernetesDeploymentInput struct {
+	// Manifests is the list of manifest file names to load, relative to the
+	// application directory. When empty, every *.yaml/*.yml file in the
+	// application directory is used.
+	Manifests []string `json:"manifests"`
+	// Namespace is the target namespace used while templating, e.g. passed
+	// to `helm template --namespace`.
+	Namespace string `json:"namespace"`
+
+	// HelmChart configures where the chart to be templated comes from.
+	HelmChart HelmChart `json:"helmChart"`
+	// HelmValueFiles is the list of values files passed to `helm template`,
+	// relative to the application directory.
+	HelmValueFiles []string `json:"helmValueFiles"`
+	// HelmReleaseName is the release name passed to `helm template`.
+	HelmReleaseName string `json:"helmReleaseName"`
+
+	// KustomizationDir is the kustomization directory passed to
+	// `kustomize build`, relative to the application directory. When
+	// empty, the application directory itself is used.
+	KustomizationDir string `json:"kustomizationDir"`
+
+	// JsonnetEntrypoint is the top-level .jsonnet file to evaluate,
+	// relative to the application directory.
+	JsonnetEntrypoint string `json:"jsonnetEntrypoint"`
+	// JsonnetLibPaths is the list of library search paths passed to the
+	// jsonnet VM, relative to the application directory.
+	JsonnetLibPaths []string `json:"jsonnetLibPaths"`
+	// JsonnetExtVars is the set of external variables (`std.extVar`) passed
+	// to the jsonnet VM.
+	JsonnetExtVars map[string]string `json:"jsonnetExtVars"`
+	// JsonnetTLAs is the set of top-level arguments passed to the jsonnet
+	// VM.
+	JsonnetTLAs map[string]string `json:"jsonnetTLAs"`
+
+	// Clusters is the list of clusters a single manifest set should be
+	// fanned out to. When empty, manifests are applied to the piped's
+	// default cluster as-is.
+	Clusters []string `json:"clusters"`
+	// ClusterOverrides maps a cluster name (from Clusters) to a set of
+	// JSON-pointer (RFC 6901) overrides applied to every manifest
+	// duplicated for that cluster.
+	ClusterOverrides map[string]map[string]interface{} `json:"clusterOverrides"`
+	// ClusterKubeContexts maps a cluster name (from Clusters) to the
+	// kubeconfig context the executor's per-cluster apply loop should use
+	// when applying that cluster's manifests.
+	ClusterKubeContexts map[string]string `json:"clusterKubeContexts"`
+}
+
+// HelmChart represents where a Helm chart should be loaded from.
+type HelmChart struct {
+	// Repository is the Helm chart repository to pull Name from. When
+	// empty, Name is treated as a directory relative to the application
+	// directory instead.
+	Repository string `json:"repository"`
+	// Name is the chart name, or a directory name when Repository is empty.
+	Name string `json:"name"`
+	// Version is the chart version to pull. Ignored when Repository is
+	// empty.
+	Version string `json:"version"`
+}